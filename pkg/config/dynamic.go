@@ -0,0 +1,36 @@
+package config
+
+// RedirectRegex holds the redirection configuration.
+type RedirectRegex struct {
+	Regex       string `json:"regex,omitempty" toml:"regex,omitempty" yaml:"regex,omitempty"`
+	Replacement string `json:"replacement,omitempty" toml:"replacement,omitempty" yaml:"replacement,omitempty"`
+	Permanent   bool   `json:"permanent,omitempty" toml:"permanent,omitempty" yaml:"permanent,omitempty"`
+	// EnvVarsAllowList restricts which environment variables the
+	// Replacement template's `.Env` helper is allowed to read.
+	EnvVarsAllowList []string `json:"envVarsAllowList,omitempty" toml:"envVarsAllowList,omitempty" yaml:"envVarsAllowList,omitempty"`
+
+	// Enabled toggles the middleware on or off without having to remove it
+	// from the configuration. It defaults to true when unset, and can be
+	// overridden at runtime by the FF_ENABLE_REDIRECTS environment variable.
+	Enabled *bool `json:"enabled,omitempty" toml:"enabled,omitempty" yaml:"enabled,omitempty"`
+	// DisabledHosts exempts requests for these hosts from the redirect,
+	// even while the middleware is otherwise enabled.
+	DisabledHosts []string `json:"disabledHosts,omitempty" toml:"disabledHosts,omitempty" yaml:"disabledHosts,omitempty"`
+	// DisabledPathPrefixes exempts requests whose path starts with one of
+	// these prefixes from the redirect, even while the middleware is
+	// otherwise enabled.
+	DisabledPathPrefixes []string `json:"disabledPathPrefixes,omitempty" toml:"disabledPathPrefixes,omitempty" yaml:"disabledPathPrefixes,omitempty"`
+	// DebugPath, if set, serves the middleware's effective compiled
+	// configuration as JSON at that request path.
+	DebugPath string `json:"debugPath,omitempty" toml:"debugPath,omitempty" yaml:"debugPath,omitempty"`
+}
+
+// RedirectRules holds the configuration for a Netlify-style "_redirects" rule set.
+//
+// Rules is a newline-separated list of rules of the form `from to [status[!]]`,
+// evaluated in order with first-match-wins semantics. RulesFile, if set, takes
+// precedence and is read from disk (or provider path) at middleware construction.
+type RedirectRules struct {
+	Rules     string `json:"rules,omitempty" toml:"rules,omitempty" yaml:"rules,omitempty"`
+	RulesFile string `json:"rulesFile,omitempty" toml:"rulesFile,omitempty" yaml:"rulesFile,omitempty"`
+}
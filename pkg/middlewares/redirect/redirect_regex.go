@@ -0,0 +1,323 @@
+package redirect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/containous/traefik/pkg/config"
+	"github.com/containous/traefik/pkg/log"
+	"github.com/containous/traefik/pkg/middlewares"
+	"github.com/containous/traefik/pkg/tracing"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+const (
+	typeName = "Redirect"
+
+	// enableRedirectsEnvVar overrides RedirectRegex.Enabled at runtime,
+	// letting operators toggle a shipped redirect config without redeploying.
+	enableRedirectsEnvVar = "FF_ENABLE_REDIRECTS"
+)
+
+// redirect is a middleware used to redirect a request matching a regex to a
+// different URL built from the regex replacement.
+type redirect struct {
+	next                 http.Handler
+	enabled              bool
+	regex                *regexp.Regexp
+	regexSource          string
+	replacementSource    string
+	template             *template.Template
+	permanent            bool
+	envAllowList         map[string]bool
+	disabledHosts        map[string]bool
+	disabledPathPrefixes []string
+	debugPath            string
+	name                 string
+}
+
+// NewRedirectRegex creates a redirect middleware. If the middleware is
+// disabled (via conf.Enabled or the FF_ENABLE_REDIRECTS environment
+// variable) and no DebugPath is configured, next is returned unwrapped, so
+// the hot path pays no regex or template compilation cost. A DebugPath keeps
+// the thin wrapper alive even while disabled, so it can keep reporting
+// enabled:false instead of vanishing.
+func NewRedirectRegex(ctx context.Context, next http.Handler, conf config.RedirectRegex, name string) (http.Handler, error) {
+	logger := log.FromContext(middlewares.GetLoggerCtx(ctx, name, typeName))
+	logger.Debug("Creating middleware")
+
+	enabled := redirectEnabled(conf)
+	if !enabled && conf.DebugPath == "" {
+		return next, nil
+	}
+
+	exp, err := regexp.Compile(strings.TrimSpace(conf.Regex))
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := compileReplacementTemplate(name, conf.Replacement)
+	if err != nil {
+		return nil, err
+	}
+
+	envAllowList := make(map[string]bool, len(conf.EnvVarsAllowList))
+	for _, v := range conf.EnvVarsAllowList {
+		envAllowList[v] = true
+	}
+
+	disabledHosts := make(map[string]bool, len(conf.DisabledHosts))
+	for _, host := range conf.DisabledHosts {
+		disabledHosts[host] = true
+	}
+
+	return &redirect{
+		next:                 next,
+		enabled:              enabled,
+		regex:                exp,
+		regexSource:          conf.Regex,
+		replacementSource:    conf.Replacement,
+		template:             tmpl,
+		permanent:            conf.Permanent,
+		envAllowList:         envAllowList,
+		disabledHosts:        disabledHosts,
+		disabledPathPrefixes: conf.DisabledPathPrefixes,
+		debugPath:            conf.DebugPath,
+		name:                 name,
+	}, nil
+}
+
+// redirectEnabled reports whether the middleware should be active, giving
+// the FF_ENABLE_REDIRECTS environment variable precedence over conf.Enabled,
+// which itself defaults to true when unset.
+func redirectEnabled(conf config.RedirectRegex) bool {
+	if raw := os.Getenv(enableRedirectsEnvVar); raw != "" {
+		if enabled, err := strconv.ParseBool(raw); err == nil {
+			return enabled
+		}
+	}
+
+	if conf.Enabled != nil {
+		return *conf.Enabled
+	}
+	return true
+}
+
+// GetTracingInformation implements tracing.Traceable.
+func (r *redirect) GetTracingInformation() (string, ext.SpanKindEnum) {
+	return r.name, tracing.SpanKindNoneEnum
+}
+
+func (r *redirect) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if r.debugPath != "" && req.URL.Path == r.debugPath {
+		r.serveDebug(rw)
+		return
+	}
+
+	if !r.enabled {
+		r.next.ServeHTTP(rw, req)
+		return
+	}
+
+	if r.isExempt(req) {
+		r.next.ServeHTTP(rw, req)
+		return
+	}
+
+	oldURL := req.URL.String()
+
+	// If the regex doesn't match, skip to the next handler.
+	if !r.regex.MatchString(oldURL) {
+		r.next.ServeHTTP(rw, req)
+		return
+	}
+
+	// Execute the (already parsed) replacement template against the current
+	// request. Its output still carries the regex's own $1, $2... references
+	// untouched, since those aren't template syntax.
+	var buf bytes.Buffer
+	if err := r.template.Execute(&buf, newTemplateData(req, r.envAllowList)); err != nil {
+		http.Error(rw, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+
+	newURL := r.regex.ReplaceAllString(oldURL, buf.String())
+
+	parsedURL, err := url.Parse(newURL)
+	if err != nil {
+		http.Error(rw, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+
+	http.Redirect(rw, req, parsedURL.String(), determineStatusCode(req.Method, r.permanent))
+}
+
+// isExempt reports whether req's host or path opts it out of this
+// middleware via DisabledHosts or DisabledPathPrefixes.
+func (r *redirect) isExempt(req *http.Request) bool {
+	if r.disabledHosts[hostWithoutPort(req.Host)] {
+		return true
+	}
+	for _, prefix := range r.disabledPathPrefixes {
+		if strings.HasPrefix(req.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostWithoutPort strips a trailing ":port" from host, so DisabledHosts
+// matches regardless of whether the request carries a non-default port.
+func hostWithoutPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// DebugInfo is the effective, compiled configuration of a RedirectRegex
+// middleware, as served by the debug endpoint at DebugPath.
+type DebugInfo struct {
+	Regex       string `json:"regex"`
+	Replacement string `json:"replacement"`
+	Permanent   bool   `json:"permanent"`
+	Enabled     bool   `json:"enabled"`
+}
+
+func (r *redirect) serveDebug(rw http.ResponseWriter) {
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(DebugInfo{
+		Regex:       r.regexSource,
+		Replacement: r.replacementSource,
+		Permanent:   r.permanent,
+		Enabled:     r.enabled,
+	})
+}
+
+// templateFuncMap holds the sprig-style helpers available to Replacement
+// templates, alongside the built-ins of text/template.
+var templateFuncMap = template.FuncMap{
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":    func(old, newStr, s string) string { return strings.ReplaceAll(s, old, newStr) },
+	"urlquery":   url.QueryEscape,
+	"default": func(def, val string) string {
+		if val == "" {
+			return def
+		}
+		return val
+	},
+}
+
+// compileReplacementTemplate parses a Replacement value once, at middleware
+// construction time, so a malformed template is reported as a configuration
+// error rather than on every matching request.
+func compileReplacementTemplate(name, replacement string) (*template.Template, error) {
+	return template.New("redirect-regex-" + name).Funcs(templateFuncMap).Parse(replacement)
+}
+
+// templateData is the context exposed to a Replacement template: the
+// current request, plus helpers for cookies, query params and allow-listed
+// environment variables.
+type templateData struct {
+	Request escapedRequest
+
+	envAllowList map[string]bool
+}
+
+// escapedRequest exposes *http.Request to a Replacement template. Host is
+// declared explicitly so it shadows the embedded, unescaped field: it's
+// fully attacker-controlled (an arbitrary Host header) and would otherwise
+// let a request inject a regexp.ReplaceAllString backreference into the
+// computed redirect target, the same way Cookie/Query values could.
+type escapedRequest struct {
+	*http.Request
+	Host string
+	URL  escapedURL
+}
+
+// escapedURL mirrors escapedRequest for *url.URL: Path, RawQuery and
+// Fragment are the request fields being matched/redirected, so they're
+// declared explicitly (escaped) to shadow the embedded, unescaped fields.
+type escapedURL struct {
+	*url.URL
+	Path     string
+	RawQuery string
+	Fragment string
+}
+
+func newTemplateData(req *http.Request, envAllowList map[string]bool) templateData {
+	return templateData{
+		Request: escapedRequest{
+			Request: req,
+			Host:    escapeDollar(req.Host),
+			URL: escapedURL{
+				URL:      req.URL,
+				Path:     escapeDollar(req.URL.Path),
+				RawQuery: escapeDollar(req.URL.RawQuery),
+				Fragment: escapeDollar(req.URL.Fragment),
+			},
+		},
+		envAllowList: envAllowList,
+	}
+}
+
+// Cookie returns the value of the named request cookie, or "" if absent.
+// The value is escaped so it can't inject a regexp.ReplaceAllString
+// backreference (e.g. "$1") into the computed redirect target.
+func (d templateData) Cookie(name string) string {
+	cookie, err := d.Request.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return escapeDollar(cookie.Value)
+}
+
+// Query returns the value of the named query parameter, or "" if absent.
+// The value is escaped so it can't inject a regexp.ReplaceAllString
+// backreference (e.g. "$1") into the computed redirect target.
+func (d templateData) Query(name string) string {
+	return escapeDollar(d.Request.URL.Query().Get(name))
+}
+
+// escapeDollar doubles up "$" so request-controlled values interpolated into
+// a Replacement template can't be mistaken for a regexp.ReplaceAllString
+// backreference ($1, ${name}) once the rendered template is handed to
+// regex.ReplaceAllString alongside the operator's own backreferences.
+func escapeDollar(s string) string {
+	return strings.ReplaceAll(s, "$", "$$")
+}
+
+// Env returns the value of the named environment variable, or "" if it isn't
+// on the middleware's configured allow-list.
+func (d templateData) Env(name string) string {
+	if !d.envAllowList[name] {
+		return ""
+	}
+	return os.Getenv(name)
+}
+
+func determineStatusCode(method string, permanent bool) int {
+	if permanent {
+		if method != http.MethodGet {
+			return http.StatusPermanentRedirect
+		}
+		return http.StatusMovedPermanently
+	}
+
+	if method != http.MethodGet {
+		return http.StatusTemporaryRedirect
+	}
+	return http.StatusFound
+}
@@ -0,0 +1,137 @@
+package redirect
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/containous/traefik/pkg/config"
+	"github.com/containous/traefik/pkg/log"
+	"github.com/containous/traefik/pkg/middlewares"
+	"github.com/containous/traefik/pkg/tracing"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const typeNameChain = "RedirectRegexChain"
+
+// redirectRegexChainRuleMatches counts, per chain name and rule index, how
+// many requests were redirected by that rule.
+var redirectRegexChainRuleMatches = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "traefik_redirect_regex_chain_rule_matches_total",
+		Help: "Total number of requests redirected by a RedirectRegexChain rule, by middleware name and rule index.",
+	},
+	[]string{"name", "rule"},
+)
+
+func init() {
+	prometheus.MustRegister(redirectRegexChainRuleMatches)
+}
+
+// chainRule is a single compiled entry of a RedirectRegexChain.
+type chainRule struct {
+	index        int
+	regex        *regexp.Regexp
+	template     *template.Template
+	permanent    bool
+	envAllowList map[string]bool
+}
+
+// redirectRegexChain is a middleware evaluating an ordered list of regex
+// rules, redirecting on the first one that matches and falling through to
+// next if none do.
+type redirectRegexChain struct {
+	next  http.Handler
+	rules []*chainRule
+	name  string
+}
+
+// NewRedirectRegexChain creates a middleware that evaluates confs in order
+// and redirects on the first matching rule, instead of requiring N stacked
+// RedirectRegex instances (and N regex evaluations per request).
+func NewRedirectRegexChain(ctx context.Context, next http.Handler, confs []config.RedirectRegex, name string) (http.Handler, error) {
+	logger := log.FromContext(middlewares.GetLoggerCtx(ctx, name, typeNameChain))
+	logger.Debug("Creating middleware")
+
+	rules := make([]*chainRule, 0, len(confs))
+	var errs []string
+	for i, conf := range confs {
+		rule, err := newChainRule(i, name, conf)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("rule %d: %v", i, err))
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid redirect regex chain: %s", strings.Join(errs, "; "))
+	}
+
+	return &redirectRegexChain{
+		next:  next,
+		rules: rules,
+		name:  name,
+	}, nil
+}
+
+func newChainRule(index int, name string, conf config.RedirectRegex) (*chainRule, error) {
+	exp, err := regexp.Compile(strings.TrimSpace(conf.Regex))
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := compileReplacementTemplate(fmt.Sprintf("%s-chain-%d", name, index), conf.Replacement)
+	if err != nil {
+		return nil, err
+	}
+
+	envAllowList := make(map[string]bool, len(conf.EnvVarsAllowList))
+	for _, v := range conf.EnvVarsAllowList {
+		envAllowList[v] = true
+	}
+
+	return &chainRule{index: index, regex: exp, template: tmpl, permanent: conf.Permanent, envAllowList: envAllowList}, nil
+}
+
+// GetTracingInformation implements tracing.Traceable.
+func (h *redirectRegexChain) GetTracingInformation() (string, ext.SpanKindEnum) {
+	return h.name, tracing.SpanKindNoneEnum
+}
+
+func (h *redirectRegexChain) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	oldURL := req.URL.String()
+
+	for _, rule := range h.rules {
+		if !rule.regex.MatchString(oldURL) {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := rule.template.Execute(&buf, newTemplateData(req, rule.envAllowList)); err != nil {
+			http.Error(rw, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+			return
+		}
+
+		newURL := rule.regex.ReplaceAllString(oldURL, buf.String())
+
+		parsedURL, err := url.Parse(newURL)
+		if err != nil {
+			http.Error(rw, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+			return
+		}
+
+		redirectRegexChainRuleMatches.WithLabelValues(h.name, strconv.Itoa(rule.index)).Inc()
+
+		http.Redirect(rw, req, parsedURL.String(), determineStatusCode(req.Method, rule.permanent))
+		return
+	}
+
+	h.next.ServeHTTP(rw, req)
+}
@@ -0,0 +1,136 @@
+package redirect
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/pkg/config"
+	"github.com/containous/traefik/pkg/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectRegexChainHandler(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		confs          []config.RedirectRegex
+		env            map[string]string
+		url            string
+		expectedURL    string
+		expectedStatus int
+		errorExpected  bool
+	}{
+		{
+			desc: "first matching rule wins",
+			confs: []config.RedirectRegex{
+				{Regex: `^http://foo\.com(.*)$`, Replacement: "https://foo.com$1"},
+				{Regex: `^http://foo\.com(.*)$`, Replacement: "https://bar.com$1"},
+			},
+			url:            "http://foo.com/path",
+			expectedURL:    "https://foo.com/path",
+			expectedStatus: http.StatusFound,
+		},
+		{
+			desc: "second rule used when first doesn't match",
+			confs: []config.RedirectRegex{
+				{Regex: `^http://bar\.com(.*)$`, Replacement: "https://bar.com$1"},
+				{Regex: `^http://foo\.com(.*)$`, Replacement: "https://foo.com$1", Permanent: true},
+			},
+			url:            "http://foo.com/path",
+			expectedURL:    "https://foo.com/path",
+			expectedStatus: http.StatusMovedPermanently,
+		},
+		{
+			desc: "no rule matches falls through to next",
+			confs: []config.RedirectRegex{
+				{Regex: `^http://bar\.com(.*)$`, Replacement: "https://bar.com$1"},
+			},
+			url:            "http://foo.com/path",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc:           "empty chain falls through to next",
+			confs:          []config.RedirectRegex{},
+			url:            "http://foo.com/path",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc: "invalid regex in one rule is reported",
+			confs: []config.RedirectRegex{
+				{Regex: `^(.*`, Replacement: "$1"},
+			},
+			url:           "http://foo.com",
+			errorExpected: true,
+		},
+		{
+			desc: "invalid replacement template in one rule is reported",
+			confs: []config.RedirectRegex{
+				{Regex: `^(.*)$`, Replacement: "{{ .Broken "},
+			},
+			url:           "http://foo.com",
+			errorExpected: true,
+		},
+		{
+			desc: "Env allow-listed per rule",
+			confs: []config.RedirectRegex{
+				{Regex: `^http://bar\.com(.*)$`, Replacement: "https://bar.com$1"},
+				{
+					Regex:            `^http://foo\.com(.*)$`,
+					Replacement:      `https://foo.com/?region={{ .Env "REGION" }}`,
+					EnvVarsAllowList: []string{"REGION"},
+				},
+			},
+			env:            map[string]string{"REGION": "eu-west"},
+			url:            "http://foo.com/path",
+			expectedURL:    "https://foo.com/?region=eu-west",
+			expectedStatus: http.StatusFound,
+		},
+		{
+			desc: "Env not allow-listed yields empty",
+			confs: []config.RedirectRegex{
+				{Regex: `^http://foo\.com(.*)$`, Replacement: `https://foo.com/?region={{ .Env "REGION" }}`},
+			},
+			env:            map[string]string{"REGION": "eu-west"},
+			url:            "http://foo.com/path",
+			expectedURL:    "https://foo.com/?region=",
+			expectedStatus: http.StatusFound,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			if test.env == nil {
+				t.Parallel()
+			}
+			for k, v := range test.env {
+				t.Setenv(k, v)
+			}
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			handler, err := NewRedirectRegexChain(context.Background(), next, test.confs, "traefikTest")
+
+			if test.errorExpected {
+				require.Error(t, err)
+				require.Nil(t, handler)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, handler)
+
+			recorder := httptest.NewRecorder()
+			r := testhelpers.MustNewRequest(http.MethodGet, test.url, nil)
+			handler.ServeHTTP(recorder, r)
+
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+
+			if test.expectedURL != "" {
+				location, err := recorder.Result().Location()
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedURL, location.String())
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package redirect
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -19,6 +20,7 @@ func TestRedirectRegexHandler(t *testing.T) {
 		config         config.RedirectRegex
 		method         string
 		url            string
+		host           string
 		secured        bool
 		expectedURL    string
 		expectedStatus int
@@ -148,6 +150,59 @@ func TestRedirectRegexHandler(t *testing.T) {
 			expectedURL:    "https://foo",
 			expectedStatus: http.StatusPermanentRedirect,
 		},
+		{
+			desc: "disabled globally is a pass-through",
+			config: config.RedirectRegex{
+				Regex:       `^http://`,
+				Replacement: "https://$1",
+				Enabled:     boolPtr(false),
+			},
+			url:            "http://foo",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc: "disabled host is exempt",
+			config: config.RedirectRegex{
+				Regex:         `^http://`,
+				Replacement:   "https://$1",
+				DisabledHosts: []string{"foo.com"},
+			},
+			url:            "http://foo.com/bar",
+			host:           "foo.com",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc: "disabled host is exempt even with a port",
+			config: config.RedirectRegex{
+				Regex:         `^http://`,
+				Replacement:   "https://$1",
+				DisabledHosts: []string{"foo.com"},
+			},
+			url:            "http://foo.com:8080/bar",
+			host:           "foo.com:8080",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc: "disabled path prefix is exempt",
+			config: config.RedirectRegex{
+				Regex:                `^http://`,
+				Replacement:          "https://$1",
+				DisabledPathPrefixes: []string{"/healthz"},
+			},
+			url:            "http://foo.com/healthz/ready",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc: "path outside a disabled prefix still redirects",
+			config: config.RedirectRegex{
+				Regex:                `^http://foo\.com(.*)$`,
+				Replacement:          "https://foo.com$1",
+				DisabledPathPrefixes: []string{"/healthz"},
+			},
+			url:            "http://foo.com/bar",
+			expectedURL:    "https://foo.com/bar",
+			expectedStatus: http.StatusFound,
+		},
 	}
 
 	for _, test := range testCases {
@@ -175,6 +230,9 @@ func TestRedirectRegexHandler(t *testing.T) {
 				if test.secured {
 					r.TLS = &tls.ConnectionState{}
 				}
+				if test.host != "" {
+					r.Host = test.host
+				}
 				r.Header.Set("X-Foo", "bar")
 				handler.ServeHTTP(recorder, r)
 
@@ -196,3 +254,267 @@ func TestRedirectRegexHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestRedirectRegexReplacementHelpers(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		config      config.RedirectRegex
+		url         string
+		host        string
+		cookie      string
+		env         map[string]string
+		expectedURL string
+	}{
+		{
+			desc: "Request.Host",
+			config: config.RedirectRegex{
+				Regex:       `^https?://[^/]+(.*)$`,
+				Replacement: `https://{{ .Request.Host }}$1`,
+			},
+			url:         "http://foo.com/bar",
+			expectedURL: "https://foo.com/bar",
+		},
+		{
+			// Fragments are never sent to a server, so .Request.URL.Fragment
+			// is exercised only as an available (if typically empty) field.
+			desc: "Request.URL scheme, path and query",
+			config: config.RedirectRegex{
+				Regex:       `^(.*)$`,
+				Replacement: `{{ .Request.URL.Scheme }}s://foo.com{{ .Request.URL.Path }}?{{ .Request.URL.RawQuery }}{{ .Request.URL.Fragment }}`,
+			},
+			url:         "http://foo.com/bar?baz=qux",
+			expectedURL: "https://foo.com/bar?baz=qux",
+		},
+		{
+			desc: "Request.Method",
+			config: config.RedirectRegex{
+				Regex:       `^(.*)$`,
+				Replacement: `https://foo.com/{{ .Request.Method }}`,
+			},
+			url:         "http://foo.com",
+			expectedURL: "https://foo.com/GET",
+		},
+		{
+			desc: "Request.RemoteAddr",
+			config: config.RedirectRegex{
+				Regex:       `^(.*)$`,
+				Replacement: `https://foo.com/?from={{ .Request.RemoteAddr | urlquery }}`,
+			},
+			url:         "http://foo.com",
+			expectedURL: "https://foo.com/?from=10.0.0.1%3A1234",
+		},
+		{
+			desc: "Cookie",
+			config: config.RedirectRegex{
+				Regex:       `^(.*)$`,
+				Replacement: `https://foo.com/?lang={{ .Cookie "lang" }}`,
+			},
+			url:         "http://foo.com",
+			cookie:      "fr",
+			expectedURL: "https://foo.com/?lang=fr",
+		},
+		{
+			desc: "Query",
+			config: config.RedirectRegex{
+				Regex:       `^(.*)$`,
+				Replacement: `https://foo.com/?tag={{ .Query "tag" }}`,
+			},
+			url:         "http://foo.com?tag=go",
+			expectedURL: "https://foo.com/?tag=go",
+		},
+		{
+			desc: "Query value can't inject a regex backreference",
+			config: config.RedirectRegex{
+				Regex:       `^(.*)$`,
+				Replacement: `https://foo.com/?tag={{ .Query "tag" }}`,
+			},
+			url:         "http://foo.com?tag=%241",
+			expectedURL: "https://foo.com/?tag=$1",
+		},
+		{
+			desc: "Cookie value can't inject a regex backreference",
+			config: config.RedirectRegex{
+				Regex:       `^(.*)$`,
+				Replacement: `https://foo.com/?lang={{ .Cookie "lang" }}`,
+			},
+			url:         "http://foo.com",
+			cookie:      "$1",
+			expectedURL: "https://foo.com/?lang=$1",
+		},
+		{
+			desc: "Request.Host value can't inject a regex backreference",
+			config: config.RedirectRegex{
+				Regex:       `^(.*)$`,
+				Replacement: `https://foo.com/?host={{ .Request.Host }}`,
+			},
+			url:         "http://foo.com/bar",
+			host:        "$1",
+			expectedURL: "https://foo.com/?host=$1",
+		},
+		{
+			desc: "Env allow-listed",
+			config: config.RedirectRegex{
+				Regex:            `^(.*)$`,
+				Replacement:      `https://foo.com/?region={{ .Env "REGION" }}`,
+				EnvVarsAllowList: []string{"REGION"},
+			},
+			url:         "http://foo.com",
+			env:         map[string]string{"REGION": "eu-west"},
+			expectedURL: "https://foo.com/?region=eu-west",
+		},
+		{
+			desc: "Env not allow-listed yields empty",
+			config: config.RedirectRegex{
+				Regex:       `^(.*)$`,
+				Replacement: `https://foo.com/?region={{ .Env "REGION" }}`,
+			},
+			url:         "http://foo.com",
+			env:         map[string]string{"REGION": "eu-west"},
+			expectedURL: "https://foo.com/?region=",
+		},
+		{
+			desc: "lower and upper",
+			config: config.RedirectRegex{
+				Regex:       `^(.*)$`,
+				Replacement: `https://foo.com/{{ .Request.Host | lower }}/{{ .Request.Host | upper }}`,
+			},
+			url:         "http://FOO.com",
+			expectedURL: "https://foo.com/foo.com/FOO.COM",
+		},
+		{
+			desc: "trimPrefix and trimSuffix",
+			config: config.RedirectRegex{
+				Regex:       `^(.*)$`,
+				Replacement: `https://foo.com/{{ trimSuffix ".com" (trimPrefix "http://" .Request.URL.String) }}`,
+			},
+			url:         "http://foo.com",
+			expectedURL: "https://foo.com/foo",
+		},
+		{
+			desc: "replace",
+			config: config.RedirectRegex{
+				Regex:       `^(.*)$`,
+				Replacement: `https://foo.com/{{ replace "o" "0" .Request.Host }}`,
+			},
+			url:         "http://foo.com",
+			expectedURL: "https://foo.com/f00.c0m",
+		},
+		{
+			desc: "default",
+			config: config.RedirectRegex{
+				Regex:       `^(.*)$`,
+				Replacement: `https://foo.com/?lang={{ default "en" (.Cookie "lang") }}`,
+			},
+			url:         "http://foo.com",
+			expectedURL: "https://foo.com/?lang=en",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			for k, v := range test.env {
+				t.Setenv(k, v)
+			}
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			handler, err := NewRedirectRegex(context.Background(), next, test.config, "traefikTest")
+			require.NoError(t, err)
+			require.NotNil(t, handler)
+
+			recorder := httptest.NewRecorder()
+			r := testhelpers.MustNewRequest(http.MethodGet, test.url, nil)
+			r.RemoteAddr = "10.0.0.1:1234"
+			if test.host != "" {
+				r.Host = test.host
+			}
+			if test.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "lang", Value: test.cookie})
+			}
+			handler.ServeHTTP(recorder, r)
+
+			location, err := recorder.Result().Location()
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedURL, location.String())
+		})
+	}
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestRedirectRegexEnabledEnvVarOverride(t *testing.T) {
+	conf := config.RedirectRegex{
+		Regex:       `^http://`,
+		Replacement: "https://$1",
+		Enabled:     boolPtr(true),
+	}
+
+	t.Setenv("FF_ENABLE_REDIRECTS", "false")
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	handler, err := NewRedirectRegex(context.Background(), next, conf, "traefikTest")
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	r := testhelpers.MustNewRequest(http.MethodGet, "http://foo", nil)
+	handler.ServeHTTP(recorder, r)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestRedirectRegexDebugEndpoint(t *testing.T) {
+	conf := config.RedirectRegex{
+		Regex:       `^http://foo(.*)$`,
+		Replacement: "https://foo$1",
+		Permanent:   true,
+		DebugPath:   "/_redirect-debug",
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler, err := NewRedirectRegex(context.Background(), next, conf, "traefikTest")
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	r := testhelpers.MustNewRequest(http.MethodGet, "http://foo/_redirect-debug", nil)
+	handler.ServeHTTP(recorder, r)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var info DebugInfo
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&info))
+	assert.Equal(t, conf.Regex, info.Regex)
+	assert.Equal(t, conf.Replacement, info.Replacement)
+	assert.True(t, info.Permanent)
+	assert.True(t, info.Enabled)
+}
+
+func TestRedirectRegexDebugEndpointReportsDisabled(t *testing.T) {
+	conf := config.RedirectRegex{
+		Regex:       `^http://foo(.*)$`,
+		Replacement: "https://foo$1",
+		Enabled:     boolPtr(false),
+		DebugPath:   "/_redirect-debug",
+	}
+
+	var nextCalled bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { nextCalled = true })
+	handler, err := NewRedirectRegex(context.Background(), next, conf, "traefikTest")
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	r := testhelpers.MustNewRequest(http.MethodGet, "http://foo/_redirect-debug", nil)
+	handler.ServeHTTP(recorder, r)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.False(t, nextCalled)
+
+	var info DebugInfo
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&info))
+	assert.False(t, info.Enabled)
+
+	// Any other path still passes straight through to next while disabled.
+	recorder = httptest.NewRecorder()
+	r = testhelpers.MustNewRequest(http.MethodGet, "http://foo/bar", nil)
+	handler.ServeHTTP(recorder, r)
+	assert.True(t, nextCalled)
+}
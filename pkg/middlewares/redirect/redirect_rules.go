@@ -0,0 +1,353 @@
+package redirect
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/containous/traefik/pkg/config"
+	"github.com/containous/traefik/pkg/log"
+	"github.com/containous/traefik/pkg/middlewares"
+)
+
+const typeNameRules = "RedirectRules"
+
+// rule is a single compiled "_redirects" rule: `from to [status[!]]`.
+type rule struct {
+	from   *regexp.Regexp
+	to     string
+	status int
+	force  bool
+}
+
+// redirectRules is a middleware evaluating a Netlify-compatible ruleset in
+// order, applying the first rule whose "from" pattern matches the request path.
+type redirectRules struct {
+	next  http.Handler
+	rules []*rule
+	name  string
+}
+
+// NewRedirectRules creates a redirect middleware from a Netlify-style
+// "_redirects" ruleset, either inline or loaded from RulesFile.
+func NewRedirectRules(ctx context.Context, next http.Handler, conf config.RedirectRules, name string) (http.Handler, error) {
+	logger := log.FromContext(middlewares.GetLoggerCtx(ctx, name, typeNameRules))
+	logger.Debug("Creating middleware")
+
+	raw, err := loadRules(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := parseRules(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &redirectRules{
+		next:  next,
+		rules: rules,
+		name:  name,
+	}, nil
+}
+
+func loadRules(conf config.RedirectRules) (string, error) {
+	if conf.RulesFile != "" {
+		content, err := ioutil.ReadFile(conf.RulesFile)
+		if err != nil {
+			return "", fmt.Errorf("reading redirect rules file %s: %w", conf.RulesFile, err)
+		}
+		return string(content), nil
+	}
+	return conf.Rules, nil
+}
+
+// parseRules parses the given "_redirects" content into an ordered list of
+// compiled rules, failing with a line/column-qualified error on the first
+// malformed line.
+func parseRules(raw string) ([]*rule, error) {
+	var rules []*rule
+
+	scanner := bufio.NewScanner(strings.NewReader(raw))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+
+		line := scanner.Text()
+		if trimmed := strings.TrimSpace(line); trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		r, err := parseRuleLine(line, lineNo)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func parseRuleLine(line string, lineNo int) (*rule, error) {
+	fields, cols := tokenizeRuleLine(line)
+	if len(fields) < 2 {
+		return nil, ruleParseErrorf(lineNo, 1, "expected a \"from\" and a \"to\", got %q", line)
+	}
+	if len(fields) > 3 {
+		return nil, ruleParseErrorf(lineNo, cols[3], "unexpected extra field %q", fields[3])
+	}
+
+	from := fields[0]
+	to := fields[1]
+
+	status := http.StatusMovedPermanently
+	force := false
+	if len(fields) == 3 {
+		statusToken := fields[2]
+		force = strings.HasSuffix(statusToken, "!")
+		statusToken = strings.TrimSuffix(statusToken, "!")
+
+		parsed, err := strconv.Atoi(statusToken)
+		if err != nil {
+			return nil, ruleParseErrorf(lineNo, cols[2], "invalid status %q", fields[2])
+		}
+		status = parsed
+	}
+
+	fromRegex, err := compileFromPattern(from)
+	if err != nil {
+		return nil, ruleParseErrorf(lineNo, cols[0], "invalid \"from\" pattern %q: %v", from, err)
+	}
+
+	return &rule{from: fromRegex, to: to, status: status, force: force}, nil
+}
+
+// compileFromPattern turns a Netlify-style "from" path, such as
+// "/news/:year/:month/:slug" or "/old/*", into an anchored regex with named
+// capture groups. A named segment (":name") captures a single path segment; a
+// trailing "*" splat captures the remainder of the path, slashes included.
+func compileFromPattern(from string) (*regexp.Regexp, error) {
+	segments := strings.Split(from, "/")
+
+	parts := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		switch {
+		case segment == "*":
+			parts = append(parts, `(?P<splat>.*)`)
+		case strings.HasPrefix(segment, ":"):
+			name := strings.TrimPrefix(segment, ":")
+			if name == "" {
+				return nil, fmt.Errorf("empty placeholder name")
+			}
+			parts = append(parts, fmt.Sprintf(`(?P<%s>[^/]+)`, name))
+		default:
+			parts = append(parts, regexp.QuoteMeta(segment))
+		}
+	}
+
+	return regexp.Compile("^" + strings.Join(parts, "/") + "$")
+}
+
+// tokenizeRuleLine splits a rule line on whitespace, returning the tokens
+// alongside their 1-based starting column, for use in error messages.
+func tokenizeRuleLine(line string) ([]string, []int) {
+	var fields []string
+	var cols []int
+
+	inField := false
+	start := 0
+	for i, r := range line {
+		if unicode.IsSpace(r) {
+			if inField {
+				fields = append(fields, line[start:i])
+				inField = false
+			}
+			continue
+		}
+		if !inField {
+			start = i
+			cols = append(cols, i+1)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, line[start:])
+	}
+
+	return fields, cols
+}
+
+func ruleParseErrorf(line, col int, format string, args ...interface{}) error {
+	return fmt.Errorf("line %d, column %d: %s", line, col, fmt.Sprintf(format, args...))
+}
+
+func (h *redirectRules) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	for _, r := range h.rules {
+		match := r.from.FindStringSubmatch(req.URL.Path)
+		if match == nil {
+			continue
+		}
+
+		target := expandPlaceholders(r.to, r.from, match)
+
+		if r.status == http.StatusOK {
+			req.URL.Path = target
+			h.next.ServeHTTP(rw, req)
+			return
+		}
+
+		location := withPreservedQuery(target, req.URL.RawQuery)
+
+		if r.force {
+			http.Redirect(rw, req, location, r.status)
+			return
+		}
+
+		// Best-effort: only redirect if nothing downstream would otherwise
+		// handle the request. Downstream writes are forwarded to rw as soon
+		// as we know the response isn't a 404, so streaming/hijacking
+		// handlers (WebSocket upgrades, SSE, chunked proxying) behind a
+		// conditional rule keep working.
+		probe := newProbeResponseWriter(rw)
+		h.next.ServeHTTP(probe, req)
+		if probe.pending404 {
+			http.Redirect(rw, req, location, r.status)
+		}
+		return
+	}
+
+	h.next.ServeHTTP(rw, req)
+}
+
+// placeholderPattern matches a ":name" placeholder token as a whole,
+// greedily consuming all following word characters so that e.g. ":idx"
+// is never mistaken for ":id" followed by a literal "x".
+var placeholderPattern = regexp.MustCompile(`:\w+`)
+
+// expandPlaceholders substitutes the named captures of match (per from's
+// subexpression names) into to, e.g. ":year" -> "2019", ":splat" -> "a/b/c".
+func expandPlaceholders(to string, from *regexp.Regexp, match []string) string {
+	values := make(map[string]string)
+	for i, name := range from.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		values[name] = match[i]
+	}
+
+	return placeholderPattern.ReplaceAllStringFunc(to, func(token string) string {
+		if v, ok := values[token[1:]]; ok {
+			return v
+		}
+		return token
+	})
+}
+
+func withPreservedQuery(target, rawQuery string) string {
+	if rawQuery == "" || strings.Contains(target, "?") {
+		return target
+	}
+	return target + "?" + rawQuery
+}
+
+// probeResponseWriter wraps a real http.ResponseWriter so the handler it's
+// passed to can be run speculatively: nothing reaches rw until the response
+// status is known. A 404 is held back entirely (so the caller can redirect
+// instead), anything else is committed to rw immediately and subsequent
+// writes/flushes/hijacks pass straight through.
+type probeResponseWriter struct {
+	rw          http.ResponseWriter
+	header      http.Header
+	code        int
+	wroteHeader bool
+	pending404  bool
+	committed   bool
+}
+
+func newProbeResponseWriter(rw http.ResponseWriter) *probeResponseWriter {
+	return &probeResponseWriter{rw: rw, header: make(http.Header)}
+}
+
+func (p *probeResponseWriter) Header() http.Header {
+	if p.committed {
+		return p.rw.Header()
+	}
+	return p.header
+}
+
+func (p *probeResponseWriter) WriteHeader(code int) {
+	if p.wroteHeader {
+		return
+	}
+	p.wroteHeader = true
+	p.code = code
+	if code == http.StatusNotFound {
+		p.pending404 = true
+		return
+	}
+	p.commit()
+}
+
+func (p *probeResponseWriter) commit() {
+	if p.committed {
+		return
+	}
+	dst := p.rw.Header()
+	for k, v := range p.header {
+		dst[k] = v
+	}
+	p.rw.WriteHeader(p.code)
+	p.committed = true
+}
+
+func (p *probeResponseWriter) Write(b []byte) (int, error) {
+	if !p.wroteHeader {
+		p.WriteHeader(http.StatusOK)
+	}
+	if p.pending404 {
+		// Discarded: the caller will replace this body with a redirect.
+		return len(b), nil
+	}
+	if !p.committed {
+		p.commit()
+	}
+	return p.rw.Write(b)
+}
+
+// Flush implements http.Flusher so downstream streaming handlers (SSE,
+// chunked proxy responses) behind a conditional rule keep working.
+func (p *probeResponseWriter) Flush() {
+	if p.pending404 {
+		return
+	}
+	if !p.wroteHeader {
+		p.WriteHeader(http.StatusOK)
+	}
+	if !p.committed {
+		p.commit()
+	}
+	if f, ok := p.rw.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker so WebSocket upgrades behind a conditional
+// rule are handed off to the real connection instead of being buffered.
+func (p *probeResponseWriter) Hijack() (net.Conn, http.ResponseWriter, error) {
+	hj, ok := p.rw.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("underlying ResponseWriter does not support hijacking")
+	}
+	p.committed = true
+	return hj.Hijack()
+}
@@ -0,0 +1,221 @@
+package redirect
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containous/traefik/pkg/config"
+	"github.com/containous/traefik/pkg/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectRulesHandler(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		config         config.RedirectRules
+		nextStatus     int
+		url            string
+		expectedURL    string
+		expectedStatus int
+		expectedPath   string
+		errorExpected  bool
+	}{
+		{
+			desc: "named placeholders",
+			config: config.RedirectRules{
+				Rules: "/news/:year/:month/:slug /blog/:year/:month/:slug 301",
+			},
+			url:            "http://foo.com/news/2019/08/hello-world",
+			expectedURL:    "/blog/2019/08/hello-world",
+			expectedStatus: http.StatusMovedPermanently,
+		},
+		{
+			desc: "overlapping placeholder names don't clobber each other",
+			config: config.RedirectRules{
+				Rules: "/a/:id/:idx /b/:idx/:id 301",
+			},
+			url:            "http://foo.com/a/1/2",
+			expectedURL:    "/b/2/1",
+			expectedStatus: http.StatusMovedPermanently,
+		},
+		{
+			desc: "trailing splat",
+			config: config.RedirectRules{
+				Rules: "/old/*  /new/:splat  302",
+			},
+			url:            "http://foo.com/old/a/b/c",
+			expectedURL:    "/new/a/b/c",
+			expectedStatus: http.StatusFound,
+		},
+		{
+			desc: "default status is 301",
+			config: config.RedirectRules{
+				Rules: "/a /b",
+			},
+			url:            "http://foo.com/a",
+			expectedURL:    "/b",
+			expectedStatus: http.StatusMovedPermanently,
+		},
+		{
+			desc: "query string is preserved",
+			config: config.RedirectRules{
+				Rules: "/a /b 301!",
+			},
+			url:            "http://foo.com/a?foo=bar",
+			expectedURL:    "/b?foo=bar",
+			expectedStatus: http.StatusMovedPermanently,
+		},
+		{
+			desc: "target query string wins over request's",
+			config: config.RedirectRules{
+				Rules: "/a /b?foo=baz 301!",
+			},
+			url:            "http://foo.com/a?foo=bar",
+			expectedURL:    "/b?foo=baz",
+			expectedStatus: http.StatusMovedPermanently,
+		},
+		{
+			desc: "forced rule short-circuits next",
+			config: config.RedirectRules{
+				Rules: "/a /b 301!",
+			},
+			nextStatus:     http.StatusOK,
+			url:            "http://foo.com/a",
+			expectedURL:    "/b",
+			expectedStatus: http.StatusMovedPermanently,
+		},
+		{
+			desc: "conditional rule yields to a handled route",
+			config: config.RedirectRules{
+				Rules: "/a /b 301",
+			},
+			nextStatus:     http.StatusOK,
+			url:            "http://foo.com/a",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc: "conditional rule fires when route is a 404",
+			config: config.RedirectRules{
+				Rules: "/a /b 301",
+			},
+			nextStatus:     http.StatusNotFound,
+			url:            "http://foo.com/a",
+			expectedURL:    "/b",
+			expectedStatus: http.StatusMovedPermanently,
+		},
+		{
+			desc: "200 status rewrites internally",
+			config: config.RedirectRules{
+				Rules: "/old/:slug /new/:slug 200",
+			},
+			nextStatus:     http.StatusOK,
+			url:            "http://foo.com/old/hello",
+			expectedStatus: http.StatusOK,
+			expectedPath:   "/new/hello",
+		},
+		{
+			desc: "no rule matches falls through to next",
+			config: config.RedirectRules{
+				Rules: "/a /b 301",
+			},
+			nextStatus:     http.StatusTeapot,
+			url:            "http://foo.com/z",
+			expectedStatus: http.StatusTeapot,
+		},
+		{
+			desc: "first match wins",
+			config: config.RedirectRules{
+				Rules: "/a /first 301!\n/a /second 301!",
+			},
+			url:            "http://foo.com/a",
+			expectedURL:    "/first",
+			expectedStatus: http.StatusMovedPermanently,
+		},
+		{
+			desc: "malformed line is rejected",
+			config: config.RedirectRules{
+				Rules: "/a",
+			},
+			errorExpected: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var gotPath string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				status := test.nextStatus
+				if status == 0 {
+					status = http.StatusNotFound
+				}
+				w.WriteHeader(status)
+			})
+
+			handler, err := NewRedirectRules(context.Background(), next, test.config, "traefikTest")
+
+			if test.errorExpected {
+				require.Error(t, err)
+				require.Nil(t, handler)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, handler)
+
+			recorder := httptest.NewRecorder()
+			r := testhelpers.MustNewRequest(http.MethodGet, test.url, nil)
+			handler.ServeHTTP(recorder, r)
+
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+
+			if test.expectedURL != "" {
+				location, err := recorder.Result().Location()
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedURL, location.String())
+			}
+
+			if test.expectedPath != "" {
+				assert.Equal(t, test.expectedPath, gotPath)
+			}
+		})
+	}
+}
+
+// flushRecorder is an httptest.ResponseRecorder that also implements
+// http.Flusher, so it can stand in for a streaming downstream connection.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushed bool
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushed = true
+}
+
+func TestRedirectRulesHandlerStreamsNonNotFoundResponses(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("chunk-1"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte("chunk-2"))
+	})
+
+	handler, err := NewRedirectRules(context.Background(), next, config.RedirectRules{
+		Rules: "/old/*  /new/:splat  302",
+	}, "traefikTest")
+	require.NoError(t, err)
+
+	recorder := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	r := testhelpers.MustNewRequest(http.MethodGet, "http://foo.com/old/a", nil)
+	handler.ServeHTTP(recorder, r)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "chunk-1chunk-2", recorder.Body.String())
+	assert.True(t, recorder.flushed)
+}